@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// StorageConfig описывает доступ к бэкендам объектного хранилища,
+// из которых UploadModule может брать src напрямую, минуя стейджинг
+// на управляющей машине.
+type StorageConfig struct {
+	S3  *S3Config  `yaml:"s3"`
+	GCS *GCSConfig `yaml:"gcs"`
+}
+
+type S3Config struct {
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+type GCSConfig struct {
+	CredentialsFile string `yaml:"credentials_file"`
+}
+
+// Storage открывает объект по ключу (без схемы) и отдаёт его как поток для чтения.
+type Storage interface {
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// openSource резолвит task.Src по схеме URI (s3://, gs://, file:// или
+// обычный локальный путь) и возвращает поток, готовый к копированию по SFTP.
+func openSource(ctx context.Context, src string, cfg StorageConfig) (io.ReadCloser, error) {
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme == "" {
+		return localStorage{}.Open(ctx, src)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return localStorage{}.Open(ctx, u.Path)
+	case "s3":
+		if cfg.S3 == nil {
+			return nil, fmt.Errorf("src %q требует секцию storage.s3 в плейбуке", src)
+		}
+		return newS3Storage(*cfg.S3).Open(ctx, strings.TrimPrefix(u.Host+u.Path, "/"))
+	case "gs":
+		if cfg.GCS == nil {
+			return nil, fmt.Errorf("src %q требует секцию storage.gcs в плейбуке", src)
+		}
+		return newGCSStorage(*cfg.GCS).Open(ctx, u.Host+u.Path)
+	default:
+		return localStorage{}.Open(ctx, src)
+	}
+}
+
+type localStorage struct{}
+
+func (localStorage) Open(_ context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// gcsStorage читает объекты из Google Cloud Storage.
+type gcsStorage struct {
+	cfg GCSConfig
+}
+
+func newGCSStorage(cfg GCSConfig) *gcsStorage {
+	return &gcsStorage{cfg: cfg}
+}
+
+// Open ожидает key в формате "bucket/object/path".
+func (g *gcsStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, object, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil, fmt.Errorf("неверный gs-путь %q, ожидалось bucket/key", key)
+	}
+
+	var opts []option.ClientOption
+	if g.cfg.CredentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(g.cfg.CredentialsFile))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка клиента GCS: %v", err)
+	}
+
+	reader, err := client.Bucket(bucket).Object(object).NewReader(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	// storage.NewClient открывает собственные соединения и должен быть закрыт
+	// сам по себе — reader этого не делает. Закрываем оба через Close вызывающей
+	// стороны, иначе каждая gs:// задача течёт клиентом.
+	return gcsReadCloser{Reader: reader, client: client}, nil
+}
+
+// gcsReadCloser закрывает и сам reader, и клиент, которым он был открыт.
+type gcsReadCloser struct {
+	*storage.Reader
+	client *storage.Client
+}
+
+func (rc gcsReadCloser) Close() error {
+	readErr := rc.Reader.Close()
+	clientErr := rc.client.Close()
+	if readErr != nil {
+		return readErr
+	}
+	return clientErr
+}
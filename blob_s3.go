@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Storage читает объекты из S3-совместимого хранилища (AWS S3, MinIO и т.п.
+// через Endpoint).
+type s3Storage struct {
+	cfg S3Config
+}
+
+func newS3Storage(cfg S3Config) *s3Storage {
+	return &s3Storage{cfg: cfg}
+}
+
+// Open ожидает key в формате "bucket/object/path".
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, object, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil, fmt.Errorf("неверный s3-путь %q, ожидалось bucket/key", key)
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(s.cfg.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			s.cfg.AccessKeyID, s.cfg.SecretAccessKey, "",
+		)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка конфигурации S3: %v", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if s.cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(s.cfg.Endpoint)
+		}
+	})
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения s3://%s: %v", key, err)
+	}
+
+	return out.Body, nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/masterzen/winrm"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// winrmChunkSize ограничивает размер одной base64-порции, отправляемой за
+// один PowerShell-вызов при заливке файла по WinRM.
+const winrmChunkSize = 8000
+
+// Communicator абстрагирует транспорт до хоста: выполнение команд и
+// передачу файлов. SSH и WinRM реализуют его по-разному, поэтому модули
+// (ScriptModule, UploadModule) больше не завязаны на конкретный протокол.
+type Communicator interface {
+	Run(ctx context.Context, cmd string) (stdout, stderr string, err error)
+	Upload(ctx context.Context, src io.Reader, dest string, mode string) error
+	Close() error
+}
+
+func newCommunicator(host HostConfig, settings Settings) (Communicator, error) {
+	switch host.Connection {
+	case "", "ssh":
+		return newSSHCommunicator(host, settings)
+	case "winrm":
+		return newWinRMCommunicator(host)
+	default:
+		return nil, fmt.Errorf("неизвестный тип соединения %q", host.Connection)
+	}
+}
+
+// sshCommunicator — транспорт по умолчанию, используется также модулями
+// docker и template, которым нужен доступ к сырому *ssh.Client.
+type sshCommunicator struct {
+	client       *ssh.Client
+	agentClient  agent.Agent
+	forwardAgent bool
+}
+
+func newSSHCommunicator(host HostConfig, settings Settings) (*sshCommunicator, error) {
+	sshConfig, err := getSSHConfig(host, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.Dial("tcp", host.Address, sshConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &sshCommunicator{client: client}
+	if host.AgentForwarding {
+		if ag, _, err := connectSSHAgent(); err == nil {
+			sc.agentClient = ag
+			sc.forwardAgent = true
+		}
+	}
+	return sc, nil
+}
+
+func (c *sshCommunicator) Run(ctx context.Context, cmd string) (string, string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", "", err
+	}
+	defer session.Close()
+
+	// Проброс ssh-agent в сессию — чтобы задачи вроде git-клонирования
+	// приватных репозиториев могли использовать ключи из локального агента.
+	if c.forwardAgent && c.agentClient != nil {
+		if err := agent.ForwardToAgent(c.client, c.agentClient); err == nil {
+			agent.RequestAgentForwarding(session)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	err = session.Run(cmd)
+	return stdout.String(), stderr.String(), err
+}
+
+func (c *sshCommunicator) Upload(ctx context.Context, src io.Reader, dest string, mode string) error {
+	sftpClient, err := sftp.NewClient(c.client)
+	if err != nil {
+		return fmt.Errorf("ошибка SFTP: %v", err)
+	}
+	defer sftpClient.Close()
+
+	fDest, err := sftpClient.Create(dest)
+	if err != nil {
+		return fmt.Errorf("не удалось создать файл на сервере: %v", err)
+	}
+	defer fDest.Close()
+
+	if _, err := io.Copy(fDest, src); err != nil {
+		return err
+	}
+
+	if mode != "" {
+		if m, err := strconv.ParseUint(mode, 8, 32); err == nil {
+			sftpClient.Chmod(dest, os.FileMode(m))
+		}
+	}
+	return nil
+}
+
+func (c *sshCommunicator) Close() error {
+	return c.client.Close()
+}
+
+// winRMCommunicator исполняет задачи на Windows-хостах через WinRM,
+// отправляя команды как PowerShell вместо `bash -e -c`.
+type winRMCommunicator struct {
+	client *winrm.Client
+}
+
+func newWinRMCommunicator(host HostConfig) (*winRMCommunicator, error) {
+	addr, portStr, err := net.SplitHostPort(host.Address)
+	if err != nil {
+		addr, portStr = host.Address, "5985"
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("неверный порт в address %q: %v", host.Address, err)
+	}
+
+	endpoint := winrm.NewEndpoint(addr, port, false, false, nil, nil, nil, 0)
+	client, err := winrm.NewClient(endpoint, host.User, host.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &winRMCommunicator{client: client}, nil
+}
+
+func (c *winRMCommunicator) Run(ctx context.Context, cmd string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	_, err := c.client.Run(winrm.Powershell(cmd), &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
+// psQuote оборачивает s в двойные кавычки для вставки в PowerShell-скрипт,
+// экранируя обратный апостроф, "$" (иначе PowerShell раскроет его как
+// переменную или $(...)) и саму двойную кавычку — аналог shQuote из git.go,
+// но под правила экранирования PowerShell, а не POSIX shell.
+func psQuote(s string) string {
+	r := strings.NewReplacer("`", "``", "$", "`$", `"`, "`\"")
+	return `"` + r.Replace(s) + `"`
+}
+
+// Upload заливает файл через серию PowerShell-команд, дописывающих
+// base64-декодированные чанки в целевой файл — у WinRM нет аналога SFTP.
+func (c *winRMCommunicator) Upload(ctx context.Context, src io.Reader, dest string, _ string) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	destPS := psQuote(dest)
+
+	init := fmt.Sprintf(`Remove-Item -Path %s -ErrorAction SilentlyContinue; New-Item -ItemType File -Path %s -Force | Out-Null`, destPS, destPS)
+	if _, stderr, err := c.Run(ctx, init); err != nil {
+		return fmt.Errorf("ошибка инициализации файла: %v | %s", err, stderr)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for i := 0; i < len(encoded); i += winrmChunkSize {
+		end := i + winrmChunkSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		mode := "Append"
+		if i == 0 {
+			mode = "Create"
+		}
+		// Пишем через [System.IO.File]::Open/.Write, а не Add-Content
+		// -Encoding Byte: последний существует только в Windows PowerShell 5.1,
+		// а WinRM на современных хостах может исполнять команды через
+		// PowerShell Core (pwsh), где такого параметра у Add-Content нет.
+		// FileStream — чистый .NET API, одинаково доступный в обоих.
+		script := fmt.Sprintf(
+			`$bytes = [System.Convert]::FromBase64String("%s"); $fs = [System.IO.File]::Open(%s, [System.IO.FileMode]::%s); $fs.Write($bytes, 0, $bytes.Length); $fs.Close()`,
+			encoded[i:end], destPS, mode,
+		)
+		if _, stderr, err := c.Run(ctx, script); err != nil {
+			return fmt.Errorf("ошибка загрузки чанка: %v | %s", err, stderr)
+		}
+	}
+	return nil
+}
+
+func (c *winRMCommunicator) Close() error {
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"golang.org/x/crypto/ssh"
+)
+
+// DockerModule управляет жизненным циклом контейнеров на удалённом хосте.
+// Вместо того чтобы выполнять `docker` как внешнюю команду, модуль туннелирует
+// Docker Engine API поверх уже установленного SSH-соединения, открывая unix-сокет
+// /var/run/docker.sock на удалённой стороне через client.Dial. Поэтому модуль
+// требует ssh-соединение и не работает с WinRM-хостами.
+type DockerModule struct{}
+
+func (m *DockerModule) Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error) {
+	start := time.Now()
+	sc, ok := comm.(*sshCommunicator)
+	if !ok {
+		return Result{}, fmt.Errorf("docker модуль требует connection: ssh")
+	}
+
+	cli, err := dockerClientOverSSH(sc.client)
+	if err != nil {
+		return Result{}, fmt.Errorf("не удалось подключиться к Docker: %v", err)
+	}
+	defer cli.Close()
+
+	action := renderTemplate(task.Action, pb.Vars)
+	if action == "" {
+		action = "run"
+	}
+	name := renderTemplate(task.ContainerName, pb.Vars)
+
+	var changed bool
+	var stdout, stderr string
+
+	switch action {
+	case "pull":
+		err = dockerPull(ctx, cli, renderTemplate(task.Image, pb.Vars))
+		changed = err == nil
+	case "run":
+		changed, err = dockerRun(ctx, cli, task, pb.Vars)
+	case "stop":
+		err = cli.ContainerStop(ctx, name, container.StopOptions{})
+		changed = err == nil
+	case "remove":
+		err = cli.ContainerRemove(ctx, name, types.ContainerRemoveOptions{Force: true})
+		changed = err == nil
+	case "exec":
+		stdout, stderr, err = dockerExec(ctx, cli, name, task, pb.Vars)
+		changed = err == nil
+	default:
+		err = fmt.Errorf("неизвестное docker-действие: %s", action)
+	}
+
+	return Result{Changed: changed, Stdout: stdout, Stderr: stderr, Duration: time.Since(start)}, err
+}
+
+// dockerClientOverSSH открывает Docker Engine API клиент, чьи HTTP-запросы
+// отправляются через unix-сокет демона, до которого достаём по уже
+// открытому SSH-соединению (без проброса локального порта).
+//
+// У http.Client намеренно нет Timeout: он по семантике net/http покрывает
+// и чтение тела ответа, а значит обрубил бы стриминг ImagePull и
+// присоединение к ContainerExecAttach на отметке в пару минут. Отмену
+// по таймауту должен задавать ctx, который пробрасывается в каждый вызов
+// Docker API (см. Execute).
+func dockerClientOverSSH(sshClient *ssh.Client) (*client.Client, error) {
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return sshClient.Dial("unix", "/var/run/docker.sock")
+			},
+		},
+	}
+
+	return client.NewClientWithOpts(
+		client.WithHTTPClient(httpClient),
+		client.WithHost("unix:///var/run/docker.sock"),
+		client.WithAPIVersionNegotiation(),
+	)
+}
+
+func dockerPull(ctx context.Context, cli *client.Client, image string) error {
+	if image == "" {
+		return fmt.Errorf("не указан image")
+	}
+	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// dockerRun приводит контейнер name к желаемому состоянию (образ, env,
+// порты, volumes). Как и template-модуль с only_if_changed, он идемпотентен:
+// если одноимённый контейнер уже запущен с тем же image, повторный запуск
+// playbook-а ничего не меняет; иначе старый контейнер удаляется перед
+// созданием нового — без этого ContainerCreate просто падает конфликтом
+// "name already in use" на втором apply.
+func dockerRun(ctx context.Context, cli *client.Client, task Task, vars map[string]interface{}) (bool, error) {
+	image := renderTemplate(task.Image, vars)
+	name := renderTemplate(task.ContainerName, vars)
+
+	if err := dockerPull(ctx, cli, image); err != nil {
+		return false, fmt.Errorf("ошибка pull: %v", err)
+	}
+
+	existing, err := cli.ContainerInspect(ctx, name)
+	switch {
+	case err == nil:
+		if existing.State != nil && existing.State.Running && existing.Config != nil && existing.Config.Image == image {
+			return false, nil
+		}
+		if err := cli.ContainerRemove(ctx, name, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return false, fmt.Errorf("не удалось удалить старый контейнер %s: %v", name, err)
+		}
+	case client.IsErrNotFound(err):
+		// контейнера ещё нет — это нормально, создаём с нуля.
+	default:
+		return false, fmt.Errorf("ошибка проверки контейнера %s: %v", name, err)
+	}
+
+	var env []string
+	for k, v := range task.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, renderTemplate(v, vars)))
+	}
+
+	exposedPorts, portBindings, err := dockerParsePorts(task.Ports)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := make([]string, 0, len(task.Command))
+	for _, c := range task.Command {
+		cmd = append(cmd, renderTemplate(c, vars))
+	}
+
+	resp, err := cli.ContainerCreate(ctx,
+		&container.Config{
+			Image:        image,
+			Env:          env,
+			Cmd:          cmd,
+			ExposedPorts: exposedPorts,
+		},
+		&container.HostConfig{
+			Binds:        task.Volumes,
+			PortBindings: portBindings,
+		},
+		nil, nil, name,
+	)
+	if err != nil {
+		return false, fmt.Errorf("ошибка создания контейнера: %v", err)
+	}
+
+	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func dockerExec(ctx context.Context, cli *client.Client, name string, task Task, vars map[string]interface{}) (string, string, error) {
+	cmd := make([]string, 0, len(task.Command))
+	for _, c := range task.Command {
+		cmd = append(cmd, renderTemplate(c, vars))
+	}
+
+	exec, err := cli.ContainerExecCreate(ctx, name, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка создания exec: %v", err)
+	}
+
+	attach, err := cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", "", fmt.Errorf("ошибка подключения exec: %v", err)
+	}
+	defer attach.Close()
+
+	// Без Tty поток мультиплексирует stdout/stderr в один Reader — демультиплексируем
+	// через stdcopy, чтобы заполнить Result.Stdout/Stderr так же, как это делает ScriptModule.
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, attach.Reader); err != nil {
+		return stdout.String(), stderr.String(), err
+	}
+
+	inspect, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return stdout.String(), stderr.String(), err
+	}
+	if inspect.ExitCode != 0 {
+		return stdout.String(), stderr.String(), fmt.Errorf("exec завершился с кодом %d", inspect.ExitCode)
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+func dockerParsePorts(ports []string) (nat.PortSet, nat.PortMap, error) {
+	exposed := nat.PortSet{}
+	bindings := nat.PortMap{}
+
+	for _, p := range ports {
+		parts := strings.SplitN(p, ":", 2)
+		if len(parts) != 2 {
+			return nil, nil, fmt.Errorf("неверный формат порта %q, ожидалось host:container", p)
+		}
+		containerPort, err := nat.NewPort("tcp", parts[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		exposed[containerPort] = struct{}{}
+		bindings[containerPort] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: parts[0]}}
+	}
+
+	return exposed, bindings, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitModule клонирует или обновляет репозиторий на удалённом хосте, выполняя
+// `git` через уже открытую SSH-сессию (как и DockerModule/TemplateModule,
+// работает только поверх connection: ssh).
+type GitModule struct{}
+
+func (m *GitModule) Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error) {
+	start := time.Now()
+	if _, ok := comm.(*sshCommunicator); !ok {
+		return Result{}, fmt.Errorf("git модуль требует connection: ssh")
+	}
+
+	repo := renderTemplate(task.Repo, pb.Vars)
+	dest := renderTemplate(task.Dest, pb.Vars)
+	version := renderTemplate(task.Version, pb.Vars)
+	keyPath := renderTemplate(task.KeyPath, pb.Vars)
+
+	// Если dest ещё не существует, rev-parse закономерно падает — HEAD "до"
+	// в этом случае просто пустой, и changed станет true после клонирования.
+	before, _ := gitRevParse(ctx, comm, dest, "HEAD")
+
+	if _, _, err := comm.Run(ctx, fmt.Sprintf("test -d %s", shQuote(dest+"/.git"))); err != nil {
+		if err := gitClone(ctx, comm, repo, dest, version, task.Depth, keyPath); err != nil {
+			return Result{Duration: time.Since(start)}, fmt.Errorf("ошибка клонирования: %v", err)
+		}
+	} else {
+		if err := gitUpdate(ctx, comm, dest, version, task.Depth, task.Force, keyPath); err != nil {
+			return Result{Duration: time.Since(start)}, fmt.Errorf("ошибка обновления: %v", err)
+		}
+	}
+
+	after, err := gitRevParse(ctx, comm, dest, "HEAD")
+	if err != nil {
+		return Result{Duration: time.Since(start)}, fmt.Errorf("не удалось определить HEAD: %v", err)
+	}
+
+	setVar(pb, "git_commit", after)
+
+	return Result{Changed: before != after, Stdout: after, Duration: time.Since(start)}, nil
+}
+
+// shaRe распознаёт version, заданный как сырой (полный или короткий) sha,
+// а не имя ветки или тега — ни один из которых не может быть построен
+// в виде origin/<version> или передан в `git clone --branch`.
+var shaRe = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+
+func gitClone(ctx context.Context, comm Communicator, repo, dest, version string, depth int, keyPath string) error {
+	args := []string{"git", "clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	// --branch принимает и ветки, и теги, но не сырой sha — тот разрешаем
+	// отдельным fetch+reset после клонирования (см. gitCheckoutVersion).
+	if version != "" && !shaRe.MatchString(version) {
+		args = append(args, "--branch", version)
+	}
+	args = append(args, repo, dest)
+
+	cmd := gitEnvPrefix(keyPath) + shJoin(args)
+	if _, stderr, err := comm.Run(ctx, cmd); err != nil {
+		return fmt.Errorf("%v | %s", err, stderr)
+	}
+
+	if version != "" && shaRe.MatchString(version) {
+		return gitCheckoutVersion(ctx, comm, dest, version, depth, keyPath)
+	}
+	return nil
+}
+
+func gitUpdate(ctx context.Context, comm Communicator, dest, version string, depth int, force bool, keyPath string) error {
+	if version != "" {
+		if err := gitCheckoutVersion(ctx, comm, dest, version, depth, keyPath); err != nil {
+			return err
+		}
+	} else {
+		fetchArgs := []string{"git", "-C", dest, "fetch", "origin"}
+		if depth > 0 {
+			fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(depth))
+		}
+		fetchCmd := gitEnvPrefix(keyPath) + shJoin(fetchArgs)
+		if _, stderr, err := comm.Run(ctx, fetchCmd); err != nil {
+			return fmt.Errorf("fetch: %v | %s", err, stderr)
+		}
+
+		resetCmd := shJoin([]string{"git", "-C", dest, "reset", "--hard", "origin/HEAD"})
+		if _, stderr, err := comm.Run(ctx, resetCmd); err != nil {
+			return fmt.Errorf("reset: %v | %s", err, stderr)
+		}
+	}
+
+	if force {
+		cleanCmd := shJoin([]string{"git", "-C", dest, "clean", "-fdx"})
+		if _, stderr, err := comm.Run(ctx, cleanCmd); err != nil {
+			return fmt.Errorf("clean: %v | %s", err, stderr)
+		}
+	}
+	return nil
+}
+
+// gitCheckoutVersion переключает dest на version, будь то ветка, тег или
+// sha коммита. В отличие от "origin/<version>" (который существует только
+// для веток) это работает одинаково для всех трёх: `git fetch origin
+// <version>` кладёт нужный коммит в FETCH_HEAD независимо от того, в каком
+// пространстве имён он живёт, после чего остаётся просто сделать reset.
+//
+// Для сырого sha это требует, чтобы сервер разрешал fetch по sha
+// (uploadpack.allowReachableSHA1InWant/allowAnySHA1InWant) — большинство
+// self-hosted серверов это позволяют, GitHub/GitLab для публичных репозиториев
+// тоже; если нет, fetch вернёт ошибку, которую мы просто пробрасываем дальше.
+func gitCheckoutVersion(ctx context.Context, comm Communicator, dest, version string, depth int, keyPath string) error {
+	fetchArgs := []string{"git", "-C", dest, "fetch"}
+	if depth > 0 {
+		fetchArgs = append(fetchArgs, "--depth", strconv.Itoa(depth))
+	}
+	fetchArgs = append(fetchArgs, "origin", version)
+
+	fetchCmd := gitEnvPrefix(keyPath) + shJoin(fetchArgs)
+	if _, stderr, err := comm.Run(ctx, fetchCmd); err != nil {
+		return fmt.Errorf("fetch %s: %v | %s", version, err, stderr)
+	}
+
+	resetCmd := shJoin([]string{"git", "-C", dest, "reset", "--hard", "FETCH_HEAD"})
+	if _, stderr, err := comm.Run(ctx, resetCmd); err != nil {
+		return fmt.Errorf("reset: %v | %s", err, stderr)
+	}
+	return nil
+}
+
+func gitRevParse(ctx context.Context, comm Communicator, dest, ref string) (string, error) {
+	cmd := fmt.Sprintf("cd %s && git rev-parse %s", shQuote(dest), shQuote(ref))
+	stdout, stderr, err := comm.Run(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("%v | %s", err, stderr)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// gitEnvPrefix подставляет приватный ключ для git-операций через
+// GIT_SSH_COMMAND, когда в задаче указан key_path.
+func gitEnvPrefix(keyPath string) string {
+	if keyPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("GIT_SSH_COMMAND=%s ", shQuote("ssh -i "+keyPath))
+}
+
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
+
+func shJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// setVar записывает факт (например git_commit) в Vars текущего хоста.
+// pb здесь — копия плейбука для конкретного хоста с собственной Vars
+// (см. cloneVars в main.go), поэтому факты одного хоста не просачиваются
+// в задачи другого хоста, выполняющегося параллельно.
+func setVar(pb *Playbook, key string, value interface{}) {
+	if pb.Vars == nil {
+		pb.Vars = map[string]interface{}{}
+	}
+	pb.Vars[key] = value
+}
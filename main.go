@@ -3,32 +3,37 @@ package main
 import (
 	"bytes"
 	"context"
+	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
-	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
 )
 
 type Settings struct {
 	FailFast bool `yaml:"fail_fast"`
+
+	KnownHostsFile        string `yaml:"known_hosts_file"`
+	StrictHostKeyChecking string `yaml:"strict_host_key_checking"` // "" (строгая проверка) | accept-new
 }
 
 type HostConfig struct {
-	Name     string `yaml:"name"`
-	Address  string `yaml:"address"`
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	KeyPath  string `yaml:"key_path"`
+	Name       string `yaml:"name"`
+	Address    string `yaml:"address"`
+	User       string `yaml:"user"`
+	Password   string `yaml:"password"`
+	KeyPath    string `yaml:"key_path"`
+	Connection string `yaml:"connection"` // ssh (по умолчанию) | winrm
+
+	KeyPassphrase   string `yaml:"key_passphrase"`
+	AgentForwarding bool   `yaml:"agent_forwarding"`
 }
 
 func (h HostConfig) ID() string {
@@ -47,102 +52,126 @@ type Task struct {
 	Src    string `yaml:"src"`
 	Dest   string `yaml:"dest"`
 	Mode   string `yaml:"mode"`
+
+	// Поля модуля docker
+	Image         string            `yaml:"image"`
+	ContainerName string            `yaml:"container_name"`
+	Ports         []string          `yaml:"ports"`
+	Env           map[string]string `yaml:"env"`
+	Volumes       []string          `yaml:"volumes"`
+	Command       []string          `yaml:"command"`
+	Action        string            `yaml:"action"` // pull, run, stop, remove, exec
+
+	// Поля модуля template
+	Owner         string `yaml:"owner"`
+	OnlyIfChanged bool   `yaml:"only_if_changed"`
+
+	// Поля модуля git
+	Repo    string `yaml:"repo"`
+	Version string `yaml:"version"`
+	Depth   int    `yaml:"depth"`
+	Force   bool   `yaml:"force"`
+	KeyPath string `yaml:"key_path"`
 }
 
 type Playbook struct {
 	Settings Settings               `yaml:"settings"`
+	Storage  StorageConfig          `yaml:"storage"`
 	Vars     map[string]interface{} `yaml:"vars"`
 	Hosts    []HostConfig           `yaml:"hosts"`
 	Tasks    []Task                 `yaml:"tasks"`
 }
 
-// Интерфейс, который должен реализовать любой модуль
+// Result — итог выполнения задачи модулем. Вместо голого error возвращается
+// структура, чтобы Reporter (см. reporter.go) мог показать changed/stdout/stderr
+// и длительность, а не только факт успеха.
+type Result struct {
+	Changed  bool
+	Stdout   string
+	Stderr   string
+	Duration time.Duration
+}
+
+// Интерфейс, который должен реализовать любой модуль.
+// Модулю передаётся Communicator вместо конкретного *ssh.Client, чтобы
+// одна и та же задача работала как по SSH, так и по WinRM (см. communicator.go),
+// а также весь плейбук, а не только vars, чтобы модуль мог читать общие
+// секции вроде storage (см. blob.go).
 type Module interface {
-	Execute(ctx context.Context, client *ssh.Client, task Task, vars map[string]interface{}) error
+	Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error)
 }
 
 var modules = map[string]Module{
-	"script": &ScriptModule{},
-	"upload": &UploadModule{},
-	// Сюда добавить новые: "git": &GitModule{}, "docker": &DockerModule{}
+	"script":   &ScriptModule{},
+	"upload":   &UploadModule{},
+	"docker":   &DockerModule{},
+	"template": &TemplateModule{},
+	"git":      &GitModule{},
 }
 
 type ScriptModule struct{}
 
-func (m *ScriptModule) Execute(ctx context.Context, client *ssh.Client, task Task, vars map[string]interface{}) error {
-	scriptCmd := renderTemplate(task.Script, vars)
+func (m *ScriptModule) Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error) {
+	start := time.Now()
+	scriptCmd := renderTemplate(task.Script, pb.Vars)
 
-	session, err := client.NewSession()
-	if err != nil {
-		return err
+	cmd := scriptCmd
+	if _, isSSH := comm.(*sshCommunicator); isSSH {
+		cmd = fmt.Sprintf("bash -e -c '%s'", strings.ReplaceAll(scriptCmd, "'", "'\\''"))
 	}
-	defer session.Close()
+	// для WinRM команда не оборачивается: sshCommunicator ждёт готовую
+	// команду для /bin/sh, а winRMCommunicator сам исполняет её через PowerShell.
 
-	var stderr bytes.Buffer
-	session.Stderr = &stderr
-
-	cmd := fmt.Sprintf("bash -e -c '%s'", strings.ReplaceAll(scriptCmd, "'", "'\\''"))
-
-	if err := session.Run(cmd); err != nil {
-		return fmt.Errorf("%v | STDERR: %s", err, stderr.String())
+	stdout, stderr, err := comm.Run(ctx, cmd)
+	res := Result{Changed: true, Stdout: stdout, Stderr: stderr, Duration: time.Since(start)}
+	if err != nil {
+		return res, fmt.Errorf("%v | STDERR: %s", err, stderr)
 	}
 
-	return nil
+	return res, nil
 }
 
 type UploadModule struct{}
 
-func (m *UploadModule) Execute(ctx context.Context, client *ssh.Client, task Task, vars map[string]interface{}) error {
-	src := renderTemplate(task.Src, vars)
-	dest := renderTemplate(task.Dest, vars)
+func (m *UploadModule) Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error) {
+	start := time.Now()
+	src := renderTemplate(task.Src, pb.Vars)
+	dest := renderTemplate(task.Dest, pb.Vars)
 
-	sftpClient, err := sftp.NewClient(client)
+	fSrc, err := openSource(ctx, src, pb.Storage)
 	if err != nil {
-		return fmt.Errorf("ошибка SFTP: %v", err)
-	}
-	defer sftpClient.Close()
-
-	fSrc, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("нет локального файла: %v", err)
+		return Result{Duration: time.Since(start)}, fmt.Errorf("нет источника %q: %v", src, err)
 	}
 	defer fSrc.Close()
 
-	fDest, err := sftpClient.Create(dest)
-	if err != nil {
-		return fmt.Errorf("не удалось создать файл на сервере: %v", err)
-	}
-	defer fDest.Close()
-
-	if _, err := io.Copy(fDest, fSrc); err != nil {
-		return err
-	}
-
-	if task.Mode != "" {
-		mode, _ := strconv.ParseUint(task.Mode, 8, 32)
-		sftpClient.Chmod(dest, os.FileMode(mode))
+	if err := comm.Upload(ctx, fSrc, dest, task.Mode); err != nil {
+		return Result{Duration: time.Since(start)}, err
 	}
 
-	fmt.Printf("    📂 Загружено: %s -> %s\n", src, dest)
-	return nil
+	return Result{Changed: true, Stdout: fmt.Sprintf("%s -> %s", src, dest), Duration: time.Since(start)}, nil
 }
 
 func main() {
-	playbookFiles := os.Args[1:]
+	silent := flag.Bool("silent", false, "не выводить прогресс и построчные события")
+	noProgress := flag.Bool("no-progress", false, "отключить прогресс-бары, выводить построчно")
+	flag.Parse()
+
+	playbookFiles := flag.Args()
 	if len(playbookFiles) == 0 {
 		playbookFiles = []string{"playbook.yaml"}
 	}
 
+	reporter := NewReporter(*silent, *noProgress)
+
 	for _, file := range playbookFiles {
-		fmt.Printf("📖 Запуск плейбука: %s\n", file)
-		if err := runPlaybook(file); err != nil {
+		if err := runPlaybook(file, reporter); err != nil {
 			log.Fatalf("⛔ Фатальная ошибка: %v", err)
 		}
 	}
-	fmt.Println("\n✨ Все задачи выполнены успешно!")
+	reporter.Finish()
 }
 
-func runPlaybook(filename string) error {
+func runPlaybook(filename string, reporter Reporter) error {
 	data, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
@@ -163,8 +192,16 @@ func runPlaybook(filename string) error {
 		wg.Add(1)
 		go func(h HostConfig) {
 			defer wg.Done()
-			if err := runHost(ctx, h, pb); err != nil {
-				fmt.Printf("❌ [%s] Ошибка: %v\n", h.ID(), err)
+			// Каждый хост получает свою копию Vars: иначе факты вроде
+			// git_commit, записанные runHost одного хоста, были бы видны
+			// (и перезаписывались бы) на остальных, так как map — ссылочный тип
+			// и копия Playbook по значению его не разделяет.
+			hostPb := pb
+			hostPb.Vars = cloneVars(pb.Vars)
+
+			err := runHost(ctx, h, hostPb, reporter)
+			reporter.HostDone(h.ID(), err)
+			if err != nil {
 				errChan <- err
 				if pb.Settings.FailFast {
 					cancel()
@@ -182,21 +219,16 @@ func runPlaybook(filename string) error {
 	return nil
 }
 
-func runHost(ctx context.Context, host HostConfig, pb Playbook) error {
-	sshConfig, err := getSSHConfig(host)
-	if err != nil {
-		return err
-	}
-
-	client, err := ssh.Dial("tcp", host.Address, sshConfig)
+func runHost(ctx context.Context, host HostConfig, pb Playbook, reporter Reporter) error {
+	comm, err := newCommunicator(host, pb.Settings)
 	if err != nil {
 		return fmt.Errorf("connection failed: %v", err)
 	}
-	defer client.Close()
+	defer comm.Close()
 
-	fmt.Printf("🔗 [%s] Подключено (%s)\n", host.ID(), host.Address)
+	reporter.HostStart(host.ID(), host.Address, len(pb.Tasks))
 
-	for _, task := range pb.Tasks {
+	for i, task := range pb.Tasks {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("прервано")
@@ -217,21 +249,26 @@ func runHost(ctx context.Context, host HostConfig, pb Playbook) error {
 		}
 
 		// Выполнение модуля
-		err := handler.Execute(ctx, client, task, pb.Vars)
+		res, err := handler.Execute(ctx, comm, task, &pb)
+		reporter.TaskDone(host.ID(), taskName, i, res, err, task.IgnoreErrors)
 
-		if err != nil {
-			if task.IgnoreErrors {
-				fmt.Printf("⚠️  [%s] %s (игнорируется): %v\n", host.ID(), taskName, err)
-			} else {
-				return fmt.Errorf("задача '%s' провалена: %v", taskName, err)
-			}
-		} else {
-			fmt.Printf("✅ [%s] %s\n", host.ID(), taskName)
+		if err != nil && !task.IgnoreErrors {
+			return fmt.Errorf("задача '%s' провалена: %v", taskName, err)
 		}
 	}
 	return nil
 }
 
+// cloneVars возвращает независимую копию vars верхнего уровня, чтобы у
+// каждого хоста была своя map фактов, а не общая на весь плейбук.
+func cloneVars(vars map[string]interface{}) map[string]interface{} {
+	clone := make(map[string]interface{}, len(vars))
+	for k, v := range vars {
+		clone[k] = v
+	}
+	return clone
+}
+
 func renderTemplate(tmplStr string, vars map[string]interface{}) string {
 	t, err := template.New("t").Parse(tmplStr)
 	if err != nil {
@@ -244,7 +281,7 @@ func renderTemplate(tmplStr string, vars map[string]interface{}) string {
 	return buf.String()
 }
 
-func getSSHConfig(host HostConfig) (*ssh.ClientConfig, error) {
+func getSSHConfig(host HostConfig, settings Settings) (*ssh.ClientConfig, error) {
 	var auth []ssh.AuthMethod
 	if host.Password != "" {
 		auth = append(auth, ssh.Password(host.Password))
@@ -252,7 +289,17 @@ func getSSHConfig(host HostConfig) (*ssh.ClientConfig, error) {
 	if host.KeyPath != "" {
 		key, err := ioutil.ReadFile(host.KeyPath)
 		if err == nil {
-			signer, err := ssh.ParsePrivateKey(key)
+			passphrase := host.KeyPassphrase
+			if passphrase == "" {
+				passphrase = os.Getenv("SSH_KEY_PASSPHRASE")
+			}
+
+			var signer ssh.Signer
+			if passphrase != "" {
+				signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+			} else {
+				signer, err = ssh.ParsePrivateKey(key)
+			}
 			if err == nil {
 				auth = append(auth, ssh.PublicKeys(signer))
 			}
@@ -260,13 +307,24 @@ func getSSHConfig(host HostConfig) (*ssh.ClientConfig, error) {
 	}
 
 	if len(auth) == 0 {
-		return nil, fmt.Errorf("нет учетных данных (password/key)")
+		if agentAuth, err := sshAgentAuthMethod(); err == nil {
+			auth = append(auth, agentAuth)
+		}
+	}
+
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("нет учетных данных (password/key/ssh-agent)")
+	}
+
+	hostKeyCallback, err := buildHostKeyCallback(settings)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка known_hosts: %v", err)
 	}
 
 	return &ssh.ClientConfig{
 		User:            host.User,
 		Auth:            auth,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 		Timeout:         10 * time.Second,
 	}, nil
 }
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"golang.org/x/term"
+)
+
+// TaskStatus — агрегированный статус задачи, в духе play recap Ansible
+// (ok/changed/failed/skipped).
+type TaskStatus string
+
+const (
+	StatusOK      TaskStatus = "ok"
+	StatusChanged TaskStatus = "changed"
+	StatusFailed  TaskStatus = "failed"
+	StatusSkipped TaskStatus = "skipped"
+)
+
+func statusFor(res Result, err error, ignored bool) TaskStatus {
+	switch {
+	case err != nil && ignored:
+		return StatusSkipped
+	case err != nil:
+		return StatusFailed
+	case res.Changed:
+		return StatusChanged
+	default:
+		return StatusOK
+	}
+}
+
+// hostStats — счётчики для итоговой таблицы по каждому хосту.
+type hostStats struct {
+	ok, changed, failed, skipped int
+}
+
+func recordStat(stats map[string]*hostStats, host string, status TaskStatus) {
+	s, ok := stats[host]
+	if !ok {
+		s = &hostStats{}
+		stats[host] = s
+	}
+	switch status {
+	case StatusOK:
+		s.ok++
+	case StatusChanged:
+		s.changed++
+	case StatusFailed:
+		s.failed++
+	case StatusSkipped:
+		s.skipped++
+	}
+}
+
+func printSummaryTable(stats map[string]*hostStats) {
+	fmt.Println("\nPLAY RECAP")
+	for host, s := range stats {
+		fmt.Printf("%-20s : ok=%d changed=%d failed=%d skipped=%d\n", host, s.ok, s.changed, s.failed, s.skipped)
+	}
+}
+
+// Reporter отделяет отображение прогресса/результатов от логики выполнения
+// плейбука, чтобы можно было подменить построчный вывод на прогресс-бары
+// или NDJSON в зависимости от окружения.
+type Reporter interface {
+	HostStart(host, address string, totalTasks int)
+	TaskDone(host, taskName string, index int, res Result, err error, ignored bool)
+	HostDone(host string, err error)
+	Finish()
+}
+
+// NewReporter выбирает реализацию в зависимости от флагов --silent/--no-progress
+// и того, подключён ли stdout к терминалу.
+func NewReporter(silent, noProgress bool) Reporter {
+	switch {
+	case silent:
+		return &silentReporter{stats: map[string]*hostStats{}}
+	case !term.IsTerminal(int(os.Stdout.Fd())):
+		return newJSONReporter()
+	case noProgress:
+		return &plainReporter{stats: map[string]*hostStats{}}
+	default:
+		return newTTYReporter()
+	}
+}
+
+// plainReporter — построчный вывод как раньше (fmt.Printf на каждый шаг),
+// используется при --no-progress.
+type plainReporter struct {
+	mu    sync.Mutex
+	stats map[string]*hostStats
+}
+
+func (r *plainReporter) HostStart(host, address string, totalTasks int) {
+	fmt.Printf("🔗 [%s] Подключено (%s)\n", host, address)
+}
+
+func (r *plainReporter) TaskDone(host, taskName string, index int, res Result, err error, ignored bool) {
+	status := statusFor(res, err, ignored)
+
+	r.mu.Lock()
+	recordStat(r.stats, host, status)
+	r.mu.Unlock()
+
+	switch status {
+	case StatusFailed:
+		fmt.Printf("❌ [%s] %s: %v\n", host, taskName, err)
+	case StatusSkipped:
+		fmt.Printf("⚠️  [%s] %s (игнорируется): %v\n", host, taskName, err)
+	default:
+		fmt.Printf("✅ [%s] %s\n", host, taskName)
+	}
+}
+
+func (r *plainReporter) HostDone(host string, err error) {
+	if err != nil {
+		fmt.Printf("❌ [%s] Ошибка: %v\n", host, err)
+	}
+}
+
+func (r *plainReporter) Finish() {
+	printSummaryTable(r.stats)
+}
+
+// silentReporter не печатает ничего, только копит статистику для Finish.
+type silentReporter struct {
+	mu    sync.Mutex
+	stats map[string]*hostStats
+}
+
+func (r *silentReporter) HostStart(host, address string, totalTasks int) {}
+
+func (r *silentReporter) TaskDone(host, taskName string, index int, res Result, err error, ignored bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recordStat(r.stats, host, statusFor(res, err, ignored))
+}
+
+func (r *silentReporter) HostDone(host string, err error) {}
+
+func (r *silentReporter) Finish() {}
+
+// jsonReporter печатает по одному NDJSON-событию на задачу — формат,
+// удобный для разбора в CI.
+type jsonReporter struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	stats map[string]*hostStats
+}
+
+func newJSONReporter() *jsonReporter {
+	return &jsonReporter{enc: json.NewEncoder(os.Stdout), stats: map[string]*hostStats{}}
+}
+
+type jsonEvent struct {
+	Host       string `json:"host"`
+	Task       string `json:"task"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r *jsonReporter) HostStart(host, address string, totalTasks int) {}
+
+func (r *jsonReporter) TaskDone(host, taskName string, index int, res Result, err error, ignored bool) {
+	status := statusFor(res, err, ignored)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recordStat(r.stats, host, status)
+
+	ev := jsonEvent{Host: host, Task: taskName, Status: string(status), DurationMs: res.Duration.Milliseconds()}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	r.enc.Encode(ev)
+}
+
+func (r *jsonReporter) HostDone(host string, err error) {}
+
+func (r *jsonReporter) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for host, s := range r.stats {
+		r.enc.Encode(map[string]interface{}{
+			"event": "recap", "host": host,
+			"ok": s.ok, "changed": s.changed, "failed": s.failed, "skipped": s.skipped,
+		})
+	}
+}
+
+// ttyReporter рисует по одному прогресс-бару на хост (текущая задача/всего,
+// прошедшее время) и печатает итоговую таблицу по завершении.
+type ttyReporter struct {
+	mu    sync.Mutex
+	pool  *pb.Pool
+	bars  map[string]*pb.ProgressBar
+	stats map[string]*hostStats
+}
+
+func newTTYReporter() *ttyReporter {
+	return &ttyReporter{bars: map[string]*pb.ProgressBar{}, stats: map[string]*hostStats{}}
+}
+
+func (r *ttyReporter) HostStart(host, address string, totalTasks int) {
+	bar := pb.New(totalTasks)
+	bar.SetTemplateString(`{{ "🔗" }} ` + host + ` {{ counters . }} {{ bar . }} {{ percent . }} {{ etime . }}`)
+
+	r.mu.Lock()
+	r.bars[host] = bar
+	r.stats[host] = &hostStats{}
+	if r.pool == nil {
+		r.pool, _ = pb.StartPool(bar)
+	} else {
+		r.pool.Add(bar)
+	}
+	r.mu.Unlock()
+}
+
+func (r *ttyReporter) TaskDone(host, taskName string, index int, res Result, err error, ignored bool) {
+	status := statusFor(res, err, ignored)
+
+	r.mu.Lock()
+	recordStat(r.stats, host, status)
+	bar := r.bars[host]
+	r.mu.Unlock()
+
+	if bar != nil {
+		bar.Increment()
+	}
+}
+
+func (r *ttyReporter) HostDone(host string, err error) {
+	r.mu.Lock()
+	bar := r.bars[host]
+	r.mu.Unlock()
+
+	if bar != nil {
+		bar.Finish()
+	}
+}
+
+func (r *ttyReporter) Finish() {
+	r.mu.Lock()
+	pool := r.pool
+	r.mu.Unlock()
+
+	if pool != nil {
+		pool.Stop()
+	}
+	printSummaryTable(r.stats)
+}
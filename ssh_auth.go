@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// connectSSHAgent открывает соединение с локальным ssh-agent через
+// SSH_AUTH_SOCK. Используется и как метод аутентификации, когда в плейбуке
+// не задан ни пароль, ни key_path, и для проброса агента в сессию.
+func connectSSHAgent() (agent.Agent, net.Conn, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, nil, fmt.Errorf("SSH_AUTH_SOCK не задан")
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, nil, err
+	}
+	return agent.NewClient(conn), conn, nil
+}
+
+func sshAgentAuthMethod() (ssh.AuthMethod, error) {
+	ag, _, err := connectSSHAgent()
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeysCallback(ag.Signers), nil
+}
+
+// buildHostKeyCallback заменяет ssh.InsecureIgnoreHostKey() на проверку по
+// known_hosts. По умолчанию читает ~/.ssh/known_hosts, путь можно
+// переопределить через settings.known_hosts_file. При
+// strict_host_key_checking: accept-new неизвестные ключи дописываются в
+// файл при первом подключении вместо отказа в соединении.
+func buildHostKeyCallback(settings Settings) (ssh.HostKeyCallback, error) {
+	path := settings.KnownHostsFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	// knownhosts.New не против отсутствующего файла не возражает только при
+	// наличии директории, поэтому создаём пустой файл заранее.
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if f, ferr := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600); ferr == nil {
+			f.Close()
+		}
+	}
+
+	cb, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать %s: %v", path, err)
+	}
+
+	if settings.StrictHostKeyChecking != "accept-new" {
+		return cb, nil
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			// Ключ для этого хоста ранее не встречался — принимаем и
+			// запоминаем его, а не отказываем в соединении.
+			return appendKnownHost(path, hostname, key)
+		}
+		return err
+	}, nil
+}
+
+func appendKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	_, err = f.WriteString(line + "\n")
+	return err
+}
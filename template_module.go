@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// TemplateModule рендерит локальный файл-шаблон через text/template и
+// заливает результат на удалённый хост по SFTP. В отличие от renderTemplate,
+// который подставляет vars в строки (имя задачи, команда скрипта и т.п.),
+// этот модуль работает с целыми файлами конфигурации. Как и DockerModule,
+// пока работает только поверх ssh-соединения (нужен прямой SFTP-доступ для
+// сравнения содержимого по SHA256).
+type TemplateModule struct{}
+
+func (m *TemplateModule) Execute(ctx context.Context, comm Communicator, task Task, pb *Playbook) (Result, error) {
+	start := time.Now()
+	sc, ok := comm.(*sshCommunicator)
+	if !ok {
+		return Result{}, fmt.Errorf("template модуль требует connection: ssh")
+	}
+
+	src := renderTemplate(task.Src, pb.Vars)
+	dest := renderTemplate(task.Dest, pb.Vars)
+
+	rendered, err := renderTemplateFile(src, pb.Vars)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, fmt.Errorf("ошибка рендера шаблона %q: %v", src, err)
+	}
+
+	sftpClient, err := sftp.NewClient(sc.client)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, fmt.Errorf("ошибка SFTP: %v", err)
+	}
+	defer sftpClient.Close()
+
+	if task.OnlyIfChanged {
+		same, err := remoteFileMatches(sftpClient, dest, rendered)
+		if err != nil {
+			return Result{Duration: time.Since(start)}, fmt.Errorf("не удалось сравнить %q: %v", dest, err)
+		}
+		if same {
+			return Result{Changed: false, Stdout: dest, Duration: time.Since(start)}, nil
+		}
+	}
+
+	fDest, err := sftpClient.Create(dest)
+	if err != nil {
+		return Result{Duration: time.Since(start)}, fmt.Errorf("не удалось создать файл на сервере: %v", err)
+	}
+	defer fDest.Close()
+
+	if _, err := fDest.Write(rendered); err != nil {
+		return Result{Duration: time.Since(start)}, err
+	}
+
+	if task.Mode != "" {
+		if mode, err := strconv.ParseUint(task.Mode, 8, 32); err == nil {
+			sftpClient.Chmod(dest, os.FileMode(mode))
+		}
+	}
+
+	if task.Owner != "" {
+		if err := chownByName(sc.client, dest, renderTemplate(task.Owner, pb.Vars)); err != nil {
+			return Result{Duration: time.Since(start)}, fmt.Errorf("не удалось сменить владельца %q: %v", dest, err)
+		}
+	}
+
+	return Result{Changed: true, Stdout: fmt.Sprintf("%s -> %s", src, dest), Duration: time.Since(start)}, nil
+}
+
+// templateFuncs — хелперы, доступные внутри шаблонов файлов (помимо
+// встроенных text/template).
+var templateFuncs = template.FuncMap{
+	"indent": func(spaces int, s string) string {
+		pad := strings.Repeat(" ", spaces)
+		return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+	},
+	"default": func(def, val interface{}) interface{} {
+		if val == nil || val == "" {
+			return def
+		}
+		return val
+	},
+	"env":   os.Getenv,
+	"quote": func(s string) string { return strconv.Quote(s) },
+}
+
+func renderTemplateFile(path string, vars map[string]interface{}) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(path).Funcs(templateFuncs).Parse(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// remoteFileMatches сравнивает SHA256 уже отрендеренного содержимого с тем,
+// что сейчас лежит на удалённом хосте, чтобы деплой конфигов был идемпотентным.
+func remoteFileMatches(sftpClient *sftp.Client, dest string, rendered []byte) (bool, error) {
+	f, err := sftpClient.Open(dest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+
+	want := sha256.Sum256(rendered)
+	got := h.Sum(nil)
+	return bytes.Equal(want[:], got), nil
+}
+
+func chownByName(client *ssh.Client, path, owner string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	cmd := fmt.Sprintf("chown '%s' '%s'", strings.ReplaceAll(owner, "'", "'\\''"), strings.ReplaceAll(path, "'", "'\\''"))
+	return session.Run(cmd)
+}